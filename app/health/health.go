@@ -0,0 +1,145 @@
+// Package health serves /healthz and /readyz HTTP endpoints reporting
+// Pub/Sub emulator connectivity and topology-reconciliation progress. It
+// exists so the setup binary can run as a Kubernetes init/sidecar container
+// with a real probe to wait on, instead of orchestrators having to infer
+// readiness from a one-shot process exit.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ENVVAR_HEALTH_ADDR names the environment variable holding the address
+// (e.g. ":8080") the health server listens on. When unset, the health
+// server is disabled.
+const ENVVAR_HEALTH_ADDR = "PUBSUB_HEALTH_ADDR"
+
+// Checker tracks emulator connectivity and topology-reconciliation progress
+// so the health server's handlers can report on it.
+type Checker struct {
+	mu            sync.RWMutex
+	emulatorReady bool
+	pending       map[string]bool // "kind/id" -> not yet confirmed to exist
+}
+
+// NewChecker returns an empty Checker. Call TrackResources with every
+// topic/subscription the topology declares before reconciliation begins, so
+// readyz starts out not-ready.
+func NewChecker() *Checker {
+	return &Checker{pending: map[string]bool{}}
+}
+
+// SetEmulatorReady records whether the most recent connectivity check
+// against the Pub/Sub emulator succeeded.
+func (c *Checker) SetEmulatorReady(ready bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emulatorReady = ready
+}
+
+// TrackResources seeds the set of resources readiness is waiting on. kind is
+// typically "topic" or "subscription".
+func (c *Checker) TrackResources(kind string, ids []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		c.pending[kind+"/"+id] = true
+	}
+}
+
+// ResourceReady marks kind/id as confirmed to exist, so readiness no longer
+// waits on it.
+func (c *Checker) ResourceReady(kind, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, kind+"/"+id)
+}
+
+// status reports emulator connectivity, overall readiness, and every
+// resource readiness is still waiting on.
+func (c *Checker) status() (emulatorReady, ready bool, pending []string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for id := range c.pending {
+		pending = append(pending, id)
+	}
+	return c.emulatorReady, c.emulatorReady && len(c.pending) == 0, pending
+}
+
+// NewServer returns an *http.Server listening on addr that serves /healthz
+// (emulator connectivity only) and /readyz (emulator connectivity plus every
+// tracked topic/subscription existing).
+func NewServer(addr string, checker *Checker) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		emulatorReady, _, _ := checker.status()
+		writeStatus(w, emulatorReady, map[string]any{"emulator": emulatorReady})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		emulatorReady, ready, pending := checker.status()
+		writeStatus(w, ready, map[string]any{"emulator": emulatorReady, "pending": pending})
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+func writeStatus(w http.ResponseWriter, ok bool, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// WaitForEmulator polls emulatorHost until a TCP connection succeeds or
+// timeout elapses, recording each attempt's outcome on checker so /healthz
+// reflects it even while this call is still blocking.
+func WaitForEmulator(emulatorHost string, checker *Checker, logger *slog.Logger, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if time.Now().After(deadline) {
+			checker.SetEmulatorReady(false)
+			return fmt.Errorf("Pub/Sub emulator did not become available within %s", timeout)
+		}
+
+		conn, err := net.Dial("tcp", emulatorHost)
+		if err == nil {
+			_ = conn.Close()
+			checker.SetEmulatorReady(true)
+			logger.Info("Pub/Sub emulator is ready", slog.String("host", emulatorHost))
+			return nil
+		}
+
+		checker.SetEmulatorReady(false)
+		logger.Debug("Waiting for Pub/Sub emulator to be ready...", slog.String("host", emulatorHost), slog.Any("error", err))
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// Serve runs server until ctx is cancelled, then shuts it down gracefully.
+func Serve(ctx context.Context, server *http.Server, logger *slog.Logger) {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down health server", slog.Any("error", err))
+		}
+	}()
+
+	logger.Info("Starting health server", slog.String("addr", server.Addr))
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error("Health server stopped", slog.Any("error", err))
+	}
+}
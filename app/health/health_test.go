@@ -0,0 +1,42 @@
+package health
+
+import "testing"
+
+func TestCheckerNotReadyUntilEmulatorAndAllResourcesReady(t *testing.T) {
+	c := NewChecker()
+	c.TrackResources("topic", []string{"orders"})
+	c.TrackResources("subscription", []string{"orders-sub"})
+
+	if _, ready, _ := c.status(); ready {
+		t.Fatal("status: ready before emulator connectivity or resources are confirmed")
+	}
+
+	c.SetEmulatorReady(true)
+	if _, ready, pending := c.status(); ready {
+		t.Fatalf("status: ready with resources still pending: %v", pending)
+	}
+
+	c.ResourceReady("topic", "orders")
+	if _, ready, pending := c.status(); ready {
+		t.Fatalf("status: ready with subscription still pending: %v", pending)
+	}
+
+	c.ResourceReady("subscription", "orders-sub")
+	emulatorReady, ready, pending := c.status()
+	if !emulatorReady || !ready || len(pending) != 0 {
+		t.Fatalf("status: emulatorReady=%v ready=%v pending=%v, want true/true/empty", emulatorReady, ready, pending)
+	}
+}
+
+func TestCheckerFlipsNotReadyWhenEmulatorDisconnects(t *testing.T) {
+	c := NewChecker()
+	c.SetEmulatorReady(true)
+	if _, ready, _ := c.status(); !ready {
+		t.Fatal("status: expected ready with no tracked resources and emulator up")
+	}
+
+	c.SetEmulatorReady(false)
+	if emulatorReady, ready, _ := c.status(); emulatorReady || ready {
+		t.Fatalf("status: emulatorReady=%v ready=%v, want false/false after disconnect", emulatorReady, ready)
+	}
+}
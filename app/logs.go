@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"regexp"
@@ -14,6 +15,21 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Environment variables read by the setup binary's logger and legacy
+// single-topic/single-subscription/single-DLT configuration. ENVVAR_ENV and
+// ENVVAR_LOG_LEVEL control the logger itself (see NewAppLogger);
+// ENVVAR_GOOGLE_PROJECT_ID is also read here as a fallback project ID for
+// trace formatting when main doesn't have one on hand yet.
+const (
+	ENVVAR_ENV               = "ENV"
+	ENVVAR_LOG_LEVEL         = "LOG_LEVEL"
+	ENVVAR_GOOGLE_PROJECT_ID = "PUBSUB_PROJECT_ID"
+	ENVVAR_EMULATOR_HOST     = "PUBSUB_EMULATOR_HOST"
+	ENVVAR_TOPIC_ID          = "PUB_SUB_TOPIC_ID"
+	ENVVAR_SUBSCRIPTION_ID   = "PUB_SUB_SUBSCRIPTION_ID"
+	ENVVAR_DLT_TOPIC_ID      = "DLT_TOPIC_ID"
+)
+
 // fmtErr returns a slog.GroupValue with keys "message" and "stacktrace".
 // This will use the stacktrace of when logging occurred, not where the error was created.
 func fmtErr(err error) slog.Value {
@@ -40,10 +56,43 @@ func traceLines() []string {
 
 var ErrUnknownLogLevel = errors.New("failed to parse log level")
 
-func NewAppLogger(env, levelStr string) (*slog.Logger, slog.Level, error) {
+// CloudLoggingReplaceAttr renames and reshapes slog attributes to match
+// Cloud Logging's structured log format. It is exported so other packages
+// that build their own slog.Handler (e.g. app/logsink) can produce entries
+// shaped the same way as the application's own logger.
+func CloudLoggingReplaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	switch attr.Key {
+	case slog.LevelKey:
+		attr.Key = "severity"
+		// Map slog.Level string values to Cloud Logging LogSeverity.
+		// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#logseverity
+		if level := attr.Value.Any().(slog.Level); level == slog.LevelWarn {
+			attr.Value = slog.StringValue("WARNING")
+		}
+	case slog.TimeKey:
+		attr.Key = "timestamp"
+	case slog.MessageKey:
+		attr.Key = "message"
+	}
+
+	if attr.Value.Kind() == slog.KindAny {
+		switch v := attr.Value.Any().(type) {
+		case error:
+			attr.Value = fmtErr(v)
+		}
+	}
+
+	return attr
+}
+
+func NewAppLogger(env, levelStr, projectID string) (*slog.Logger, slog.Level, error) {
 	var err error
 	logLevel := slog.LevelInfo
 
+	if projectID == "" {
+		projectID = os.Getenv(ENVVAR_GOOGLE_PROJECT_ID)
+	}
+
 	if levelStr != "" {
 		err = logLevel.UnmarshalText([]byte(levelStr))
 		if err != nil {
@@ -53,32 +102,8 @@ func NewAppLogger(env, levelStr string) (*slog.Logger, slog.Level, error) {
 	}
 
 	logHandlerOpts := slog.HandlerOptions{
-		Level: logLevel,
-		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
-			// Rename attribute keys to match Cloud Logging structured log format.
-			switch attr.Key {
-			case slog.LevelKey:
-				attr.Key = "severity"
-				// Map slog.Level string values to Cloud Logging LogSeverity.
-				// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#logseverity
-				if level := attr.Value.Any().(slog.Level); level == slog.LevelWarn {
-					attr.Value = slog.StringValue("WARNING")
-				}
-			case slog.TimeKey:
-				attr.Key = "timestamp"
-			case slog.MessageKey:
-				attr.Key = "message"
-			}
-
-			if attr.Value.Kind() == slog.KindAny {
-				switch v := attr.Value.Any().(type) {
-				case error:
-					attr.Value = fmtErr(v)
-				}
-			}
-
-			return attr
-		},
+		Level:       logLevel,
+		ReplaceAttr: CloudLoggingReplaceAttr,
 	}
 
 	var handler slog.Handler
@@ -95,17 +120,22 @@ func NewAppLogger(env, levelStr string) (*slog.Logger, slog.Level, error) {
 		handler = slog.NewJSONHandler(os.Stderr, &logHandlerOpts)
 	}
 
-	logger := slog.New(handlerWithSpanContext(handler))
+	logger := slog.New(handlerWithSpanContext(handler, projectID))
 	return logger, logLevel, err
 }
 
-func handlerWithSpanContext(handler slog.Handler) *spanContextLogHandler {
-	return &spanContextLogHandler{Handler: handler}
+func handlerWithSpanContext(handler slog.Handler, projectID string) *spanContextLogHandler {
+	return &spanContextLogHandler{Handler: handler, projectID: projectID}
 }
 
 // spanContextLogHandler is an slog.Handler which adds attributes from the span context
 type spanContextLogHandler struct {
 	slog.Handler
+	// projectID is the GCP project used to build the fully-qualified
+	// "logging.googleapis.com/trace" resource name. If empty, the raw
+	// trace ID is emitted instead and Cloud Logging will not be able to
+	// correlate the log entry with its trace.
+	projectID string
 }
 
 // Handle overrides slog.Handler's Handle method. This adds attributes from the span context to the slog.Record.
@@ -115,7 +145,7 @@ func (t *spanContextLogHandler) Handle(ctx context.Context, record slog.Record)
 		// Add trace context attributes following Cloud Logging structured log format described
 		// in https://cloud.google.com/logging/docs/structured-logging#special-payloads-fields
 		record.AddAttrs(
-			slog.Any("logging.googleapis.com/trace", s.TraceID()),
+			slog.Any("logging.googleapis.com/trace", t.formatTrace(s.TraceID())),
 		)
 		record.AddAttrs(
 			slog.Any("logging.googleapis.com/spanId", s.SpanID()),
@@ -127,10 +157,21 @@ func (t *spanContextLogHandler) Handle(ctx context.Context, record slog.Record)
 	return t.Handler.Handle(ctx, record)
 }
 
+// formatTrace returns traceID as the fully-qualified Cloud Logging trace
+// resource name (projects/{PROJECT_ID}/traces/{TRACE_ID}) when a project ID
+// is configured, so that the Trace Explorer can correlate the log entry with
+// its trace. Falls back to the raw trace ID otherwise.
+func (t *spanContextLogHandler) formatTrace(traceID trace.TraceID) string {
+	if t.projectID == "" {
+		return traceID.String()
+	}
+	return fmt.Sprintf("projects/%s/traces/%s", t.projectID, traceID.String())
+}
+
 func (t *spanContextLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return handlerWithSpanContext(t.Handler.WithAttrs(attrs))
+	return handlerWithSpanContext(t.Handler.WithAttrs(attrs), t.projectID)
 }
 
 func (t *spanContextLogHandler) WithGroup(name string) slog.Handler {
-	return handlerWithSpanContext(t.Handler.WithGroup(name))
+	return handlerWithSpanContext(t.Handler.WithGroup(name), t.projectID)
 }
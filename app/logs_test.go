@@ -0,0 +1,58 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanContext returns a valid, sampled trace.SpanContext for tests.
+func spanContext() trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+}
+
+func TestSpanContextLogHandlerFormatsFullyQualifiedTraceWithProjectID(t *testing.T) {
+	var buf bytes.Buffer
+	handler := handlerWithSpanContext(slog.NewJSONHandler(&buf, nil), "my-project")
+	logger := slog.New(handler)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext())
+	logger.InfoContext(ctx, "hello")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshalling log entry: %v", err)
+	}
+
+	want := "projects/my-project/traces/" + spanContext().TraceID().String()
+	if got := entry["logging.googleapis.com/trace"]; got != want {
+		t.Errorf("trace = %v, want %q", got, want)
+	}
+}
+
+func TestSpanContextLogHandlerFallsBackToRawTraceIDWithoutProjectID(t *testing.T) {
+	var buf bytes.Buffer
+	handler := handlerWithSpanContext(slog.NewJSONHandler(&buf, nil), "")
+	logger := slog.New(handler)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext())
+	logger.InfoContext(ctx, "hello")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshalling log entry: %v", err)
+	}
+
+	want := spanContext().TraceID().String()
+	if got := entry["logging.googleapis.com/trace"]; got != want {
+		t.Errorf("trace = %v, want %q", got, want)
+	}
+}
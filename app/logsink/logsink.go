@@ -0,0 +1,153 @@
+// Package logsink mirrors Pub/Sub traffic into a local, Cloud
+// Logging-formatted JSONL stream. It exists so integration tests have a
+// single place to assert against messages emitted during a test run, in the
+// same shape Cloud Logging would produce for them in production.
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/setup-pubsub-emulator/app"
+)
+
+// Mode controls what happens to a message once it has been mirrored.
+type Mode int
+
+const (
+	// Drain acknowledges every message after writing it, permanently
+	// removing it from the subscription's backlog.
+	Drain Mode = iota
+	// Peek nacks every message after writing it, leaving it pending on the
+	// mirror subscription for redelivery.
+	Peek
+)
+
+// MirrorSubSuffix is appended to a topic ID to name the dedicated pull
+// subscription Sink.Run creates for mirroring that topic, e.g.
+// "orders" -> "orders-logsink". Mirroring never reuses an application's own
+// subscription IDs: doing so would steal or re-nack messages the real
+// consumer is also trying to receive. It is an alias of app.LogSinkSubSuffix
+// so Setup.Reconcile's --prune can recognize and preserve these
+// subscriptions too.
+const MirrorSubSuffix = app.LogSinkSubSuffix
+
+// Sink mirrors every message received on one or more topics into a Cloud
+// Logging-formatted JSONL stream.
+type Sink struct {
+	logger *slog.Logger
+	mode   Mode
+}
+
+// New returns a Sink that writes one Cloud Logging-formatted JSONL entry to
+// out per received Pub/Sub message, using the same attribute mapping as
+// app.NewAppLogger.
+func New(out io.Writer, mode Mode) *Sink {
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
+		ReplaceAttr: app.CloudLoggingReplaceAttr,
+	})
+	return &Sink{logger: slog.New(handler), mode: mode}
+}
+
+// Run ensures a dedicated "{topicID}-logsink" pull subscription exists for
+// every topic in topicIDs before starting to mirror any of them, so a
+// failure partway through never leaves an earlier topic's Receive loop
+// running unsupervised. It then mirrors each message received on those
+// subscriptions until ctx is cancelled or a subscription's Receive call
+// returns an irrecoverable error. Mirroring is additive: it never attaches
+// to an application's own subscriptions, so it cannot steal or re-nack
+// messages those consumers are also trying to receive.
+func (s *Sink) Run(ctx context.Context, client *pubsub.Client, topicIDs []string) error {
+	subs := make([]*pubsub.Subscription, 0, len(topicIDs))
+	for _, topicID := range topicIDs {
+		sub, err := ensureMirrorSubscription(ctx, client, topicID)
+		if err != nil {
+			return fmt.Errorf("ensuring mirror subscription for topic %q: %w", topicID, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(subs))
+
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *pubsub.Subscription, subID string) {
+			defer wg.Done()
+
+			err := sub.Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
+				s.mirror(subID, msg)
+				if s.mode == Drain {
+					msg.Ack()
+				} else {
+					msg.Nack()
+				}
+			})
+			if err != nil {
+				errs <- fmt.Errorf("receiving from subscription %q: %w", subID, err)
+				// Stop the other subscriptions' Receive loops too, so Run
+				// returns this error instead of waiting on them.
+				cancel()
+			}
+		}(sub, sub.ID())
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureMirrorSubscription returns the dedicated mirror subscription for
+// topicID, creating it as a plain pull subscription if it does not already
+// exist.
+func ensureMirrorSubscription(ctx context.Context, client *pubsub.Client, topicID string) (*pubsub.Subscription, error) {
+	subID := topicID + MirrorSubSuffix
+	sub := client.Subscription(subID)
+
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("checking subscription %q existence: %w", subID, err)
+	}
+	if exists {
+		return sub, nil
+	}
+
+	sub, err = client.CreateSubscription(ctx, subID, pubsub.SubscriptionConfig{
+		Topic: client.Topic(topicID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating subscription %q: %w", subID, err)
+	}
+	return sub, nil
+}
+
+// mirror writes msg as a Cloud Logging-formatted structured log entry.
+func (s *Sink) mirror(subID string, msg *pubsub.Message) {
+	attrs := []slog.Attr{
+		slog.String("subscriptionID", subID),
+		slog.String("messageID", msg.ID),
+	}
+
+	for key, value := range msg.Attributes {
+		if key == "trace" {
+			attrs = append(attrs, slog.Any("logging.googleapis.com/trace", value))
+			continue
+		}
+		attrs = append(attrs, slog.String(key, value))
+	}
+
+	s.logger.LogAttrs(context.Background(), slog.LevelInfo, string(msg.Data), attrs...)
+}
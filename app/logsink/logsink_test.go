@@ -0,0 +1,237 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestClient returns a pubsub.Client backed by an in-memory pstest fake,
+// along with a cleanup func the caller must defer.
+func newTestClient(t *testing.T) (*pubsub.Client, func()) {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing pstest server: %v", err)
+	}
+
+	client, err := pubsub.NewClient(context.Background(), "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("creating pubsub client: %v", err)
+	}
+
+	return client, func() {
+		client.Close()
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestSinkRunDoesNotStealFromApplicationSubscription(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, err := client.CreateTopic(ctx, "orders")
+	if err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	appSub, err := client.CreateSubscription(ctx, "orders-sub", pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	// A real subscriber only receives messages published after it was
+	// created, so the mirror subscription must exist before we publish.
+	if _, err := ensureMirrorSubscription(ctx, client, "orders"); err != nil {
+		t.Fatalf("ensureMirrorSubscription: %v", err)
+	}
+
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte("hello")}).Get(ctx); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	sink := New(&lockedWriter{w: &buf, mu: &mu}, Drain)
+
+	sinkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := sink.Run(sinkCtx, client, []string{"orders"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	mu.Lock()
+	mirrored := buf.String()
+	mu.Unlock()
+	if mirrored == "" {
+		t.Fatal("expected sink to mirror the message, got no output")
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(mirrored[:bytes.IndexByte([]byte(mirrored), '\n')]), &entry); err != nil {
+		t.Fatalf("unmarshalling mirrored entry: %v", err)
+	}
+	if entry["message"] != "hello" {
+		t.Errorf("entry[message] = %v, want %q", entry["message"], "hello")
+	}
+
+	mirrorSub := client.Subscription("orders" + MirrorSubSuffix)
+	exists, err := mirrorSub.Exists(ctx)
+	if err != nil {
+		t.Fatalf("checking mirror subscription existence: %v", err)
+	}
+	if !exists {
+		t.Fatal("expected sink to create a dedicated mirror subscription, none found")
+	}
+
+	// The real consumer's own subscription must still see the message: the
+	// sink must never have attached to (and acked/nacked on) orders-sub.
+	recvCtx, recvCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer recvCancel()
+	received := false
+	err = appSub.Receive(recvCtx, func(_ context.Context, msg *pubsub.Message) {
+		received = true
+		msg.Ack()
+		recvCancel()
+	})
+	if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+		t.Fatalf("Receive on app subscription: %v", err)
+	}
+	if !received {
+		t.Error("application subscription never saw the message; sink stole it")
+	}
+}
+
+func TestSinkRunDrainAcksMirroredMessages(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, err := client.CreateTopic(ctx, "orders")
+	if err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	if _, err := ensureMirrorSubscription(ctx, client, "orders"); err != nil {
+		t.Fatalf("ensureMirrorSubscription: %v", err)
+	}
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte("hello")}).Get(ctx); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	sink := New(&lockedWriter{w: &buf, mu: &mu}, Drain)
+
+	runSinkUntilFirstMessage(ctx, t, sink, client, "orders", &buf, &mu)
+
+	// Acked messages must not come back: a fresh Receive should see nothing.
+	if received := tryReceiveOne(ctx, t, client.Subscription("orders"+MirrorSubSuffix)); received {
+		t.Error("message redelivered after drain; drain must permanently ack it")
+	}
+}
+
+func TestSinkRunPeekNacksMirroredMessages(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, err := client.CreateTopic(ctx, "orders")
+	if err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	if _, err := ensureMirrorSubscription(ctx, client, "orders"); err != nil {
+		t.Fatalf("ensureMirrorSubscription: %v", err)
+	}
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte("hello")}).Get(ctx); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	sink := New(&lockedWriter{w: &buf, mu: &mu}, Peek)
+
+	runSinkUntilFirstMessage(ctx, t, sink, client, "orders", &buf, &mu)
+
+	// Nacked messages must still be pending: a fresh Receive on the mirror
+	// subscription should see it again, proving peek left it unacked there.
+	if received := tryReceiveOne(ctx, t, client.Subscription("orders"+MirrorSubSuffix)); !received {
+		t.Error("message never redelivered after peek; peek must leave it pending via nack")
+	}
+}
+
+// runSinkUntilFirstMessage runs sink against topicID until buf has received
+// at least one mirrored entry, then stops it.
+func runSinkUntilFirstMessage(ctx context.Context, t *testing.T, sink *Sink, client *pubsub.Client, topicID string, buf *bytes.Buffer, mu *sync.Mutex) {
+	t.Helper()
+
+	sinkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- sink.Run(sinkCtx, client, []string{topicID}) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := buf.Len()
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	mu.Lock()
+	mirrored := buf.String()
+	mu.Unlock()
+	if mirrored == "" {
+		t.Fatal("expected sink to mirror the message, got no output")
+	}
+}
+
+// tryReceiveOne does a single short-lived Receive on sub and reports whether
+// a message arrived, acking it if so.
+func tryReceiveOne(ctx context.Context, t *testing.T, sub *pubsub.Subscription) bool {
+	t.Helper()
+
+	recvCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	received := false
+	err := sub.Receive(recvCtx, func(_ context.Context, msg *pubsub.Message) {
+		received = true
+		msg.Ack()
+		cancel()
+	})
+	if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+		t.Fatalf("Receive: %v", err)
+	}
+	return received
+}
+
+// lockedWriter serializes writes from Sink's concurrent Receive callbacks so
+// tests can safely inspect the buffer after Run returns.
+type lockedWriter struct {
+	w  *bytes.Buffer
+	mu *sync.Mutex
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,99 @@
+package logsink
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultMaxBytes is the size at which a RotatingWriter rolls its current
+// file over to a timestamped backup before continuing to write.
+const defaultMaxBytes = 10 * 1024 * 1024 // 10 MiB
+
+// RotatingWriter is an io.WriteCloser that appends to path, rotating the
+// file to path.<timestamp>-<seq> once it grows past maxBytes.
+type RotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+
+	file        *os.File
+	size        int64
+	rotationSeq int
+}
+
+// NewRotatingWriter opens (or creates) path for appending and returns a
+// writer that rotates it once it exceeds maxBytes. A maxBytes of 0 uses
+// defaultMaxBytes.
+func NewRotatingWriter(path string, maxBytes int64) (*RotatingWriter, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+
+	w := &RotatingWriter{path: path, maxBytes: maxBytes}
+	if err := w.openCurrent(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingWriter) openCurrent() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening logsink file %q: %w", w.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat-ing logsink file %q: %w", w.path, err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating the underlying file first if it is
+// non-empty and p would push it past maxBytes. A write to an empty file is
+// never rotated, even if p alone exceeds maxBytes.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("closing logsink file %q before rotation: %w", w.path, err)
+	}
+
+	// Second-resolution timestamps alone can collide under sustained load
+	// (multiple rotations within the same wall-clock second), and
+	// os.Rename would then silently overwrite the earlier backup. Append a
+	// per-writer monotonic sequence number to keep every backup distinct.
+	w.rotationSeq++
+	backupPath := fmt.Sprintf("%s.%s-%d", w.path, time.Now().Format("20060102T150405"), w.rotationSeq)
+	if err := os.Rename(w.path, backupPath); err != nil {
+		return fmt.Errorf("rotating logsink file %q: %w", w.path, err)
+	}
+
+	return w.openCurrent()
+}
+
+// Close implements io.Closer.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,95 @@
+package logsink
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingWriterRotatesPastMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink.jsonl")
+
+	w, err := NewRotatingWriter(path, 10)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("12345")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Fatalf("expected no rotation yet, got entries=%v err=%v", entries, err)
+	}
+
+	if _, err := w.Write([]byte("678901")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected current file plus one rotated backup, got %d entries: %v", len(entries), entries)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading current file: %v", err)
+	}
+	if string(current) != "678901" {
+		t.Errorf("current file content = %q, want %q", current, "678901")
+	}
+}
+
+func TestRotatingWriterDisambiguatesBackupsWithinSameSecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink.jsonl")
+
+	w, err := NewRotatingWriter(path, 5)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	defer w.Close()
+
+	// These rotations land back-to-back, well within the same wall-clock
+	// second on any reasonably fast test machine; each must still get its
+	// own backup file instead of a later one overwriting an earlier one.
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte("123456")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected current file plus 2 distinct rotated backups, got %d entries: %v", len(entries), entries)
+	}
+}
+
+func TestRotatingWriterDoesNotRotateUnderMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink.jsonl")
+
+	w, err := NewRotatingWriter(path, 1024)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("line\n")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected no rotation, got %d entries: %v", len(entries), entries)
+	}
+}
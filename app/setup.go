@@ -0,0 +1,296 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/setup-pubsub-emulator/app/topology"
+	"google.golang.org/api/iterator"
+)
+
+// LogSinkSubSuffix is appended to a topic ID to name the dedicated pull
+// subscription app/logsink creates for mirroring that topic, e.g.
+// "orders" -> "orders-logsink". Reconcile's pruning must never delete these:
+// they are created on demand after a Reconcile run, not declared in the
+// topology file, and would otherwise look like undeclared leftovers on the
+// very next --prune.
+const LogSinkSubSuffix = "-logsink"
+
+// Action describes what Reconcile or an Ensure* method did to a single
+// resource.
+type Action string
+
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionSkipped Action = "skipped"
+	ActionDeleted Action = "deleted"
+	ActionFailed  Action = "failed"
+)
+
+// Result records what happened to a single topic or subscription during a
+// Reconcile call.
+type Result struct {
+	Kind   string // "topic" or "subscription"
+	ID     string
+	Action Action
+	Err    error
+}
+
+// Report is the structured diff returned by Reconcile.
+type Report struct {
+	Results []Result
+}
+
+func (r *Report) record(kind, id string, action Action, err error) {
+	if err != nil {
+		action = ActionFailed
+	}
+	r.Results = append(r.Results, Result{Kind: kind, ID: id, Action: action, Err: err})
+}
+
+// Setup drives idempotent Pub/Sub emulator (or project) setup: creating
+// topics, subscriptions, and dead-letter topics, and reconciling them
+// against a declarative topology.TopologySpec. Unlike main, a Setup never
+// exits the process; every failure is returned as an error so it can be
+// imported and driven from other Go test binaries (e.g. from TestMain)
+// instead of shelling out to the setup binary.
+type Setup struct {
+	Client *pubsub.Client
+	Logger *slog.Logger
+}
+
+// NewSetup returns a Setup that drives client. If logger is nil,
+// slog.Default() is used.
+func NewSetup(client *pubsub.Client, logger *slog.Logger) *Setup {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Setup{Client: client, Logger: logger}
+}
+
+// EnsureTopic creates the topic identified by id if it does not already
+// exist, applying no schema or retention config. It is a thin wrapper
+// around EnsureTopicWithSpec for callers (like EnsureDeadLetter) that only
+// have an id, not a full topology.TopicSpec.
+func (s *Setup) EnsureTopic(ctx context.Context, id string) (*pubsub.Topic, Action, error) {
+	return s.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: id})
+}
+
+// EnsureTopicWithSpec creates the topic described by spec if it does not
+// already exist, applying spec's message retention and schema at creation
+// time. If the topic already exists, its retention is updated in place when
+// spec declares one; schema can only be set at creation, so an existing
+// topic's schema is left untouched.
+func (s *Setup) EnsureTopicWithSpec(ctx context.Context, spec topology.TopicSpec) (*pubsub.Topic, Action, error) {
+	topic := s.Client.Topic(spec.ID)
+
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, ActionFailed, fmt.Errorf("checking topic %q existence: %w", spec.ID, err)
+	}
+
+	if !exists {
+		topicConfig := pubsub.TopicConfig{}
+		if spec.MessageRetention > 0 {
+			topicConfig.RetentionDuration = spec.MessageRetention
+		}
+		if spec.Schema != nil {
+			topicConfig.SchemaSettings = &pubsub.SchemaSettings{
+				Schema:   spec.Schema.Name,
+				Encoding: pubsub.EncodingJSON,
+			}
+			if spec.Schema.Encoding == "BINARY" {
+				topicConfig.SchemaSettings.Encoding = pubsub.EncodingBinary
+			}
+		}
+
+		topic, err = s.Client.CreateTopicWithConfig(ctx, spec.ID, &topicConfig)
+		if err != nil {
+			return nil, ActionFailed, fmt.Errorf("creating topic %q: %w", spec.ID, err)
+		}
+		return topic, ActionCreated, nil
+	}
+
+	if spec.MessageRetention > 0 {
+		if _, err := topic.Update(ctx, pubsub.TopicConfigToUpdate{RetentionDuration: spec.MessageRetention}); err != nil {
+			return nil, ActionFailed, fmt.Errorf("updating topic %q: %w", spec.ID, err)
+		}
+		return topic, ActionUpdated, nil
+	}
+
+	return topic, ActionSkipped, nil
+}
+
+// EnsureDeadLetter creates the dead-letter topic identified by id if it does
+// not already exist. It is a more descriptively named wrapper around
+// EnsureTopic for callers building a dead-letter policy.
+func (s *Setup) EnsureDeadLetter(ctx context.Context, id string) (*pubsub.Topic, Action, error) {
+	return s.EnsureTopic(ctx, id)
+}
+
+// EnsureSubscription creates or updates the subscription described by spec
+// so it is attached to topic.
+func (s *Setup) EnsureSubscription(ctx context.Context, topic *pubsub.Topic, spec topology.SubscriptionSpec) (Action, error) {
+	sub := s.Client.Subscription(spec.ID)
+
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return ActionFailed, fmt.Errorf("checking subscription %q existence: %w", spec.ID, err)
+	}
+
+	subConfig := pubsub.SubscriptionConfig{
+		Topic:                 topic,
+		AckDeadline:           spec.AckDeadline,
+		RetainAckedMessages:   spec.RetainAckedMessages,
+		RetentionDuration:     spec.MessageRetention,
+		EnableMessageOrdering: spec.EnableMessageOrdering,
+		Filter:                spec.Filter,
+	}
+
+	if spec.Push != nil {
+		pushConfig := pubsub.PushConfig{Endpoint: spec.Push.Endpoint}
+		if spec.Push.Auth != nil {
+			pushConfig.AuthenticationMethod = &pubsub.OIDCToken{
+				ServiceAccountEmail: spec.Push.Auth.ServiceAccountEmail,
+				Audience:            spec.Push.Auth.Audience,
+			}
+		}
+		subConfig.PushConfig = pushConfig
+	}
+
+	if spec.DeadLetter != nil {
+		subConfig.DeadLetterPolicy = &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     s.Client.Topic(spec.DeadLetter.TopicID).String(),
+			MaxDeliveryAttempts: spec.DeadLetter.MaxDeliveryAttempts,
+		}
+	}
+
+	if spec.RetryPolicy != nil {
+		subConfig.RetryPolicy = &pubsub.RetryPolicy{
+			MinimumBackoff: spec.RetryPolicy.MinimumBackoff,
+			MaximumBackoff: spec.RetryPolicy.MaximumBackoff,
+		}
+	}
+
+	if !exists {
+		if _, err := s.Client.CreateSubscription(ctx, spec.ID, subConfig); err != nil {
+			return ActionFailed, fmt.Errorf("creating subscription %q: %w", spec.ID, err)
+		}
+		return ActionCreated, nil
+	}
+
+	update := pubsub.SubscriptionConfigToUpdate{
+		AckDeadline:         subConfig.AckDeadline,
+		RetainAckedMessages: subConfig.RetainAckedMessages,
+		DeadLetterPolicy:    subConfig.DeadLetterPolicy,
+		RetryPolicy:         subConfig.RetryPolicy,
+	}
+	if spec.MessageRetention > 0 {
+		update.RetentionDuration = spec.MessageRetention
+	}
+	if spec.Push != nil {
+		update.PushConfig = &subConfig.PushConfig
+	}
+	if _, err := sub.Update(ctx, update); err != nil {
+		return ActionFailed, fmt.Errorf("updating subscription %q: %w", spec.ID, err)
+	}
+	return ActionUpdated, nil
+}
+
+// Reconcile makes the emulator (or project) match spec: every declared
+// topic and subscription is created if missing and updated in place where
+// the Pub/Sub API supports in-place updates. When prune is true, topics and
+// subscriptions that exist but are not declared in spec are deleted, except
+// log sink mirror subscriptions (IDs ending in LogSinkSubSuffix), which are
+// kept.
+func (s *Setup) Reconcile(ctx context.Context, spec *topology.TopologySpec, prune bool) (Report, error) {
+	var report Report
+
+	declaredTopics := map[string]bool{}
+	declaredSubs := map[string]bool{}
+
+	for _, topicSpec := range spec.Topics {
+		declaredTopics[topicSpec.ID] = true
+		for _, subSpec := range topicSpec.Subscriptions {
+			declaredSubs[subSpec.ID] = true
+		}
+		if topicSpec.ID == "" {
+			report.record("topic", "", ActionFailed, fmt.Errorf("topic spec missing id"))
+			continue
+		}
+
+		topic, action, err := s.EnsureTopicWithSpec(ctx, topicSpec)
+		report.record("topic", topicSpec.ID, action, err)
+		if err != nil {
+			s.Logger.ErrorContext(ctx, "Failed to reconcile topic", slog.String("topicID", topicSpec.ID), slog.Any("error", err))
+			continue
+		}
+
+		for _, subSpec := range topicSpec.Subscriptions {
+			action, err := s.EnsureSubscription(ctx, topic, subSpec)
+			report.record("subscription", subSpec.ID, action, err)
+			if err != nil {
+				s.Logger.ErrorContext(ctx, "Failed to reconcile subscription", slog.String("subID", subSpec.ID), slog.Any("error", err))
+			}
+		}
+	}
+
+	if prune {
+		if err := s.pruneTopics(ctx, declaredTopics, &report); err != nil {
+			return report, fmt.Errorf("failed to prune topics: %w", err)
+		}
+		if err := s.pruneSubscriptions(ctx, declaredSubs, &report); err != nil {
+			return report, fmt.Errorf("failed to prune subscriptions: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (s *Setup) pruneTopics(ctx context.Context, declared map[string]bool, report *Report) error {
+	it := s.Client.Topics(ctx)
+	for {
+		topic, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := topic.ID()
+		if declared[id] {
+			continue
+		}
+		if err := topic.Delete(ctx); err != nil {
+			report.record("topic", id, ActionFailed, err)
+			continue
+		}
+		report.record("topic", id, ActionDeleted, nil)
+	}
+}
+
+func (s *Setup) pruneSubscriptions(ctx context.Context, declared map[string]bool, report *Report) error {
+	it := s.Client.Subscriptions(ctx)
+	for {
+		sub, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := sub.ID()
+		if declared[id] || strings.HasSuffix(id, LogSinkSubSuffix) {
+			continue
+		}
+		if err := sub.Delete(ctx); err != nil {
+			report.record("subscription", id, ActionFailed, err)
+			continue
+		}
+		report.record("subscription", id, ActionDeleted, nil)
+	}
+}
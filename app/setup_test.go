@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/setup-pubsub-emulator/app/topology"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestSetup returns a Setup backed by an in-memory pstest fake, along
+// with a cleanup func the caller must defer.
+func newTestSetup(t *testing.T) (*Setup, func()) {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing pstest server: %v", err)
+	}
+
+	client, err := pubsub.NewClient(context.Background(), "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("creating pubsub client: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(nopWriter{}, nil))
+	return NewSetup(client, logger), func() {
+		client.Close()
+		conn.Close()
+		srv.Close()
+	}
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestEnsureTopicWithSpecCreatesMissingTopic(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, action, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec: %v", err)
+	}
+	if action != ActionCreated {
+		t.Errorf("action = %q, want %q", action, ActionCreated)
+	}
+}
+
+func TestEnsureTopicWithSpecSkipsExistingTopicWithNoRetention(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"}); err != nil {
+		t.Fatalf("EnsureTopicWithSpec (create): %v", err)
+	}
+
+	_, action, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec (re-run): %v", err)
+	}
+	if action != ActionSkipped {
+		t.Errorf("action = %q, want %q", action, ActionSkipped)
+	}
+}
+
+func TestEnsureTopicWithSpecUpdatesRetentionOnExistingTopic(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"}); err != nil {
+		t.Fatalf("EnsureTopicWithSpec (create): %v", err)
+	}
+
+	_, action, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders", MessageRetention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec (update retention): %v", err)
+	}
+	if action != ActionUpdated {
+		t.Errorf("action = %q, want %q", action, ActionUpdated)
+	}
+}
+
+func TestEnsureSubscriptionCreatesPullSubscription(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec: %v", err)
+	}
+
+	action, err := setup.EnsureSubscription(ctx, topic, topology.SubscriptionSpec{ID: "orders-sub"})
+	if err != nil {
+		t.Fatalf("EnsureSubscription: %v", err)
+	}
+	if action != ActionCreated {
+		t.Errorf("action = %q, want %q", action, ActionCreated)
+	}
+}
+
+func TestEnsureSubscriptionUpdatesExistingPullSubscription(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec: %v", err)
+	}
+
+	spec := topology.SubscriptionSpec{ID: "orders-sub", AckDeadline: 10 * time.Second}
+	if _, err := setup.EnsureSubscription(ctx, topic, spec); err != nil {
+		t.Fatalf("EnsureSubscription (create): %v", err)
+	}
+
+	spec.AckDeadline = 30 * time.Second
+	action, err := setup.EnsureSubscription(ctx, topic, spec)
+	if err != nil {
+		t.Fatalf("EnsureSubscription (update): %v", err)
+	}
+	if action != ActionUpdated {
+		t.Errorf("action = %q, want %q", action, ActionUpdated)
+	}
+}
+
+func TestReconcilePrunesUndeclaredTopics(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "stale-topic"}); err != nil {
+		t.Fatalf("seeding stale topic: %v", err)
+	}
+
+	report, err := setup.Reconcile(ctx, &topology.TopologySpec{
+		Topics: []topology.TopicSpec{{ID: "orders"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	var sawCreated, sawDeleted bool
+	for _, result := range report.Results {
+		switch {
+		case result.ID == "orders" && result.Action == ActionCreated:
+			sawCreated = true
+		case result.ID == "stale-topic" && result.Action == ActionDeleted:
+			sawDeleted = true
+		}
+	}
+	if !sawCreated {
+		t.Error("expected report to record orders topic as created")
+	}
+	if !sawDeleted {
+		t.Error("expected report to record stale-topic as deleted when prune=true")
+	}
+}
+
+func TestReconcilePrunePreservesLogSinkSubscriptions(t *testing.T) {
+	setup, cleanup := newTestSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	topic, _, err := setup.EnsureTopicWithSpec(ctx, topology.TopicSpec{ID: "orders"})
+	if err != nil {
+		t.Fatalf("EnsureTopicWithSpec: %v", err)
+	}
+	if _, err := setup.Client.CreateSubscription(ctx, "orders"+LogSinkSubSuffix, pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+		t.Fatalf("seeding log sink subscription: %v", err)
+	}
+
+	if _, err := setup.Reconcile(ctx, &topology.TopologySpec{
+		Topics: []topology.TopicSpec{{ID: "orders"}},
+	}, true); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	exists, err := setup.Client.Subscription("orders" + LogSinkSubSuffix).Exists(ctx)
+	if err != nil {
+		t.Fatalf("checking log sink subscription existence: %v", err)
+	}
+	if !exists {
+		t.Error("expected --prune to preserve the undeclared log sink subscription, it was deleted")
+	}
+}
@@ -0,0 +1,40 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ENVVAR_TOPOLOGY_FILE names the environment variable holding the path to a
+// topology config file. When unset, callers should fall back to whatever
+// single-topic/single-subscription defaults they already support.
+const ENVVAR_TOPOLOGY_FILE = "PUBSUB_TOPOLOGY_FILE"
+
+// Load reads and parses a topology config file. The format is chosen from
+// the file extension: ".yaml"/".yml" for YAML, ".json" for JSON.
+func Load(path string) (*TopologySpec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file %q: %w", path, err)
+	}
+
+	var spec TopologySpec
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(raw, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse topology file %q as YAML: %w", path, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse topology file %q as JSON: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported topology file extension %q (expected .yaml, .yml, or .json)", ext)
+	}
+
+	return &spec, nil
+}
@@ -0,0 +1,77 @@
+package topology
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadParsesYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	const doc = `
+topics:
+  - id: orders
+    messageRetention: 24h
+    subscriptions:
+      - id: orders-sub
+        ackDeadline: 30s
+        deadLetter:
+          topicId: orders-dlt
+          maxDeliveryAttempts: 5
+  - id: orders-dlt
+`
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(spec.Topics) != 2 {
+		t.Fatalf("len(Topics) = %d, want 2", len(spec.Topics))
+	}
+	orders := spec.Topics[0]
+	if orders.ID != "orders" || orders.MessageRetention != 24*time.Hour {
+		t.Errorf("orders topic = %+v, want id=orders messageRetention=24h", orders)
+	}
+	if len(orders.Subscriptions) != 1 || orders.Subscriptions[0].ID != "orders-sub" {
+		t.Fatalf("orders.Subscriptions = %+v", orders.Subscriptions)
+	}
+	sub := orders.Subscriptions[0]
+	if sub.AckDeadline != 30*time.Second {
+		t.Errorf("sub.AckDeadline = %s, want 30s", sub.AckDeadline)
+	}
+	if sub.DeadLetter == nil || sub.DeadLetter.TopicID != "orders-dlt" || sub.DeadLetter.MaxDeliveryAttempts != 5 {
+		t.Errorf("sub.DeadLetter = %+v", sub.DeadLetter)
+	}
+}
+
+func TestLoadParsesJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.json")
+	const doc = `{"topics": [{"id": "orders", "messageRetention": 86400000000000}]}`
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(spec.Topics) != 1 || spec.Topics[0].ID != "orders" {
+		t.Fatalf("Topics = %+v", spec.Topics)
+	}
+}
+
+func TestLoadRejectsUnsupportedExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.toml")
+	if err := os.WriteFile(path, []byte("topics = []"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("Load: expected error for unsupported extension, got nil")
+	}
+}
@@ -0,0 +1,69 @@
+// Package topology describes a declarative Pub/Sub topology (topics,
+// subscriptions, and dead-letter topics) and loads it from a YAML or JSON
+// file. Reconciling it against a live Pub/Sub (or emulator) project is done
+// by app.Setup.Reconcile, not by this package.
+package topology
+
+import "time"
+
+// TopologySpec is the root of a topology config file. It describes every
+// topic the setup binary should ensure exists, along with the subscriptions
+// attached to each one.
+type TopologySpec struct {
+	Topics []TopicSpec `yaml:"topics" json:"topics"`
+}
+
+// TopicSpec describes a single Pub/Sub topic and the subscriptions that
+// should be attached to it.
+type TopicSpec struct {
+	ID               string             `yaml:"id" json:"id"`
+	MessageRetention time.Duration      `yaml:"messageRetention,omitempty" json:"messageRetention,omitempty"`
+	Schema           *SchemaRef         `yaml:"schema,omitempty" json:"schema,omitempty"`
+	Subscriptions    []SubscriptionSpec `yaml:"subscriptions,omitempty" json:"subscriptions,omitempty"`
+}
+
+// SchemaRef points a topic at a pre-registered Pub/Sub schema.
+type SchemaRef struct {
+	Name     string `yaml:"name" json:"name"`
+	Encoding string `yaml:"encoding" json:"encoding"`
+}
+
+// DeadLetterSpec mirrors pubsub.DeadLetterPolicy. TopicID must refer to
+// another topic declared elsewhere in the TopologySpec (it is created like
+// any other topic, it just happens to receive dead-lettered messages).
+type DeadLetterSpec struct {
+	TopicID             string `yaml:"topicId" json:"topicId"`
+	MaxDeliveryAttempts int    `yaml:"maxDeliveryAttempts" json:"maxDeliveryAttempts"`
+}
+
+// RetryPolicySpec mirrors pubsub.RetryPolicy.
+type RetryPolicySpec struct {
+	MinimumBackoff time.Duration `yaml:"minimumBackoff,omitempty" json:"minimumBackoff,omitempty"`
+	MaximumBackoff time.Duration `yaml:"maximumBackoff,omitempty" json:"maximumBackoff,omitempty"`
+}
+
+// PushConfig describes a push subscription endpoint. A nil PushConfig on a
+// SubscriptionSpec means the subscription is a pull subscription.
+type PushConfig struct {
+	Endpoint string    `yaml:"endpoint" json:"endpoint"`
+	Auth     *PushAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+}
+
+// PushAuth configures OIDC token authentication on a push subscription.
+type PushAuth struct {
+	ServiceAccountEmail string `yaml:"serviceAccountEmail" json:"serviceAccountEmail"`
+	Audience            string `yaml:"audience,omitempty" json:"audience,omitempty"`
+}
+
+// SubscriptionSpec describes a single subscription attached to a topic.
+type SubscriptionSpec struct {
+	ID                    string           `yaml:"id" json:"id"`
+	Push                  *PushConfig      `yaml:"push,omitempty" json:"push,omitempty"`
+	AckDeadline           time.Duration    `yaml:"ackDeadline,omitempty" json:"ackDeadline,omitempty"`
+	MessageRetention      time.Duration    `yaml:"messageRetention,omitempty" json:"messageRetention,omitempty"`
+	RetainAckedMessages   bool             `yaml:"retainAckedMessages,omitempty" json:"retainAckedMessages,omitempty"`
+	EnableMessageOrdering bool             `yaml:"enableMessageOrdering,omitempty" json:"enableMessageOrdering,omitempty"`
+	Filter                string           `yaml:"filter,omitempty" json:"filter,omitempty"`
+	DeadLetter            *DeadLetterSpec  `yaml:"deadLetter,omitempty" json:"deadLetter,omitempty"`
+	RetryPolicy           *RetryPolicySpec `yaml:"retryPolicy,omitempty" json:"retryPolicy,omitempty"`
+}
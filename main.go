@@ -3,21 +3,37 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/joho/godotenv"
 	"github.com/setup-pubsub-emulator/app"
+	"github.com/setup-pubsub-emulator/app/health"
+	"github.com/setup-pubsub-emulator/app/logsink"
+	"github.com/setup-pubsub-emulator/app/topology"
 )
 
+// ENVVAR_LOGSINK_FILE names the environment variable holding the path to the
+// JSONL file the log sink mirrors Pub/Sub messages into. When unset, the log
+// sink is disabled.
+const ENVVAR_LOGSINK_FILE = "PUBSUB_LOGSINK_FILE"
+
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	prune := flag.Bool("prune", false, "delete topics and subscriptions that exist but are not declared in the topology file (log sink mirror subscriptions are kept)")
+	peek := flag.Bool("peek", false, "log sink mode: nack every mirrored message after copying it, instead of the default of acking it (drain)")
+	flag.Parse()
 
 	// --- Dotenv initialization ---
 	pwd, err := os.Getwd()
@@ -34,14 +50,13 @@ func main() {
 
 	emulatorHost := os.Getenv(app.ENVVAR_EMULATOR_HOST)
 	projectID := os.Getenv(app.ENVVAR_GOOGLE_PROJECT_ID)
-	topicID := os.Getenv(app.ENVVAR_TOPIC_ID)
-	subID := os.Getenv(app.ENVVAR_SUBSCRIPTION_ID)
-	dltID := os.Getenv(app.ENVVAR_DLT_TOPIC_ID)
+	topologyFile := os.Getenv(topology.ENVVAR_TOPOLOGY_FILE)
 
 	// --- Logger Setup ---
 	logger, logLevel, err := app.NewAppLogger(
 		os.Getenv(app.ENVVAR_ENV),
 		os.Getenv(app.ENVVAR_LOG_LEVEL),
+		projectID,
 	)
 
 	// Set default logger and level early
@@ -68,8 +83,23 @@ func main() {
 	if projectID == "" {
 		log.Fatalln("PUBSUB_PROJECT_ID environment variable not set. Please set it to your dummy project ID.")
 	}
-	if topicID == "" || subID == "" || dltID == "" {
-		log.Fatalln("PUB_SUB_TOPIC_ID, PUB_SUB_SUBSCRIPTION_ID, or DLT_TOPIC_ID environment variable(s) not set.")
+
+	topologySpec, err := loadTopologySpec(topologyFile)
+	if err != nil {
+		log.Fatalf("Failed to load topology: %v", err)
+	}
+
+	checker := health.NewChecker()
+	for _, topicSpec := range topologySpec.Topics {
+		checker.TrackResources("topic", []string{topicSpec.ID})
+		for _, subSpec := range topicSpec.Subscriptions {
+			checker.TrackResources("subscription", []string{subSpec.ID})
+		}
+	}
+
+	healthAddr := os.Getenv(health.ENVVAR_HEALTH_ADDR)
+	if healthAddr != "" {
+		go health.Serve(ctx, health.NewServer(healthAddr, checker), logger)
 	}
 
 	addr, err := net.ResolveTCPAddr("tcp", emulatorHost)
@@ -78,7 +108,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = waitForPubSubEmulator(addr.String(), logger, 30*time.Second)
+	err = health.WaitForEmulator(addr.String(), checker, logger, 30*time.Second)
 	if err != nil {
 		logger.Error("Pub/Sub emulator not ready", slog.Any("error", err))
 		os.Exit(1)
@@ -92,90 +122,100 @@ func main() {
 
 	logger.InfoContext(ctx, fmt.Sprintf("Connecting to Pub/Sub emulator at %s for project %s", slog.String("emulatorHost", emulatorHost), slog.String("projectID", projectID)))
 
-	// --- 1. Create Dead-Letter Topic (DLT) ---
-	logger.InfoContext(ctx, "Creating DLT topic...", slog.String("dltID", dltID))
-	dltTopic := client.Topic(dltID)
-	exists, err := dltTopic.Exists(ctx)
-	if err != nil {
-		log.Fatalf("Failed to check DLT topic existence: %v", err)
-	}
-	if exists {
-		logger.WarnContext(ctx, fmt.Sprintf("DLT topic '%s' already exists.", slog.String("dltID", dltID)))
-	} else {
-		_, err = client.CreateTopic(ctx, dltID)
-		if err != nil {
-			log.Fatalf("Failed to create DLT topic '%s': %v", dltID, err)
-		}
-		logger.InfoContext(ctx, fmt.Sprintf("DLT topic '%s' created successfully.", slog.String("dltID", dltID)))
-	}
+	setup := app.NewSetup(client, logger)
 
-	// --- 2. Create Main Topic ---
-	logger.InfoContext(ctx, fmt.Sprintf("Creating main topic '%s'...", slog.String("topicID", topicID)))
-	topic := client.Topic(topicID)
-	exists, err = topic.Exists(ctx)
+	report, err := setup.Reconcile(ctx, topologySpec, *prune)
 	if err != nil {
-		log.Fatalf("Failed to check main topic existence: %v", err)
+		log.Fatalf("Failed to reconcile topology: %v", err)
 	}
-	if exists {
-		logger.WarnContext(ctx, fmt.Sprintf("Main topic '%s' already exists.", slog.String("topicID", topicID)))
-	} else {
-		_, err = client.CreateTopic(ctx, topicID)
-		if err != nil {
-			log.Fatalf("Failed to create main topic '%s': %v", topicID, err)
+	for _, result := range report.Results {
+		if result.Err != nil {
+			logger.ErrorContext(ctx, "Failed to reconcile resource", slog.String("kind", result.Kind), slog.String("id", result.ID), slog.Any("error", result.Err))
+			continue
+		}
+		logger.InfoContext(ctx, "Reconciled resource", slog.String("kind", result.Kind), slog.String("id", result.ID), slog.String("action", string(result.Action)))
+		if result.Action != app.ActionDeleted {
+			checker.ResourceReady(result.Kind, result.ID)
 		}
-		logger.InfoContext(ctx, fmt.Sprintf("Main topic '%s' created successfully.", slog.String("topicID", topicID)))
 	}
+	logger.InfoContext(ctx, "Pub/Sub emulator setup complete.")
 
-	// --- 3. Create Pull Subscription ---
-	logger.InfoContext(ctx, fmt.Sprintf("Creating PULL subscription '%s' to topic '%s'...", slog.String("subID", subID), slog.String("topicID", topicID)))
-
-	sub := client.Subscription(subID)
-	exists, err = sub.Exists(ctx)
-	if err != nil {
-		log.Fatalf("Failed to check subscription existence: %v", err)
+	if logsinkFile := os.Getenv(ENVVAR_LOGSINK_FILE); logsinkFile != "" {
+		runLogSink(ctx, client, topologySpec, logsinkFile, *peek, logger)
+	} else if healthAddr != "" {
+		// The health server only has value as a long-running probe: keep the
+		// process alive (as a Kubernetes init/sidecar container would expect)
+		// until it is asked to shut down, instead of exiting the moment
+		// reconciliation finishes.
+		logger.InfoContext(ctx, "Setup complete; health server running until shutdown signal.")
+		<-ctx.Done()
 	}
-	if exists {
-		logger.WarnContext(ctx, fmt.Sprintf("Subscription '%s' already exists. Deleting and recreating for fresh config.", slog.String("subID", subID)))
-		if err := sub.Delete(ctx); err != nil {
-			log.Fatalf("Failed to delete existing subscription '%s': %v", subID, err)
-		}
-		logger.InfoContext(ctx, fmt.Sprintf("Existing subscription '%s' deleted.", slog.String("subID", subID)))
+}
+
+// loadTopologySpec returns the TopologySpec to reconcile against. When
+// topologyFile is set it is loaded from disk; otherwise a single
+// topic/subscription/DLT spec is built from the legacy
+// PUB_SUB_TOPIC_ID/PUB_SUB_SUBSCRIPTION_ID/DLT_TOPIC_ID environment
+// variables, preserving the setup binary's original behavior.
+func loadTopologySpec(topologyFile string) (*topology.TopologySpec, error) {
+	if topologyFile != "" {
+		return topology.Load(topologyFile)
 	}
 
-	subConfig := pubsub.SubscriptionConfig{
-		Topic: topic,
-		DeadLetterPolicy: &pubsub.DeadLetterPolicy{
-			DeadLetterTopic:     dltTopic.String(), // Full topic name: "projects/PROJECT_ID/topics/TOPIC_ID"
-			MaxDeliveryAttempts: 10,
+	topicID := os.Getenv(app.ENVVAR_TOPIC_ID)
+	subID := os.Getenv(app.ENVVAR_SUBSCRIPTION_ID)
+	dltID := os.Getenv(app.ENVVAR_DLT_TOPIC_ID)
+	if topicID == "" || subID == "" || dltID == "" {
+		return nil, errors.New("PUB_SUB_TOPIC_ID, PUB_SUB_SUBSCRIPTION_ID, or DLT_TOPIC_ID environment variable(s) not set")
+	}
+
+	return &topology.TopologySpec{
+		Topics: []topology.TopicSpec{
+			{ID: dltID},
+			{
+				ID: topicID,
+				Subscriptions: []topology.SubscriptionSpec{
+					{
+						ID:          subID,
+						AckDeadline: 60 * time.Second,
+						DeadLetter: &topology.DeadLetterSpec{
+							TopicID:             dltID,
+							MaxDeliveryAttempts: 10,
+						},
+					},
+				},
+			},
 		},
-		AckDeadline: 60 * time.Second, // Max time for worker to acknowledge
-	}
+	}, nil
+}
 
-	_, err = client.CreateSubscription(ctx, subID, subConfig)
+// runLogSink mirrors every message received on a dedicated "{topicID}-logsink"
+// subscription per topic in spec into logsinkFile as Cloud Logging-formatted
+// JSONL, until ctx is cancelled. It never attaches to spec's own declared
+// subscriptions, so it cannot steal or re-nack messages the real consumers
+// are also trying to receive.
+func runLogSink(ctx context.Context, client *pubsub.Client, spec *topology.TopologySpec, logsinkFile string, peek bool, logger *slog.Logger) {
+	writer, err := logsink.NewRotatingWriter(logsinkFile, 0)
 	if err != nil {
-		log.Fatalf("Failed to create push subscription '%s': %v", subID, err)
+		logger.ErrorContext(ctx, "Failed to open log sink file", slog.String("file", logsinkFile), slog.Any("error", err))
+		return
 	}
-	logger.InfoContext(ctx, fmt.Sprintf("PULL subscription '%s' created successfully.", slog.String("subID", subID)))
-
-	logger.InfoContext(ctx, "Pub/Sub emulator setup complete.")
-}
+	defer writer.Close()
 
-func waitForPubSubEmulator(emulatorHost string, logger *slog.Logger, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
+	mode := logsink.Drain
+	if peek {
+		mode = logsink.Peek
+	}
 
-	for {
-		if time.Now().After(deadline) {
-			return fmt.Errorf("Pub/Sub emulator did not become available within %s", timeout)
-		}
+	var topicIDs []string
+	for _, topicSpec := range spec.Topics {
+		topicIDs = append(topicIDs, topicSpec.ID)
+	}
 
-		conn, err := net.Dial("tcp", emulatorHost)
-		if err == nil {
-			_ = conn.Close()
-			logger.Info("Pub/Sub emulator is ready", slog.String("host", emulatorHost))
-			return nil
-		}
+	logger.InfoContext(ctx, "Starting log sink", slog.String("file", logsinkFile), slog.Int("topics", len(topicIDs)))
 
-		logger.Debug("Waiting for Pub/Sub emulator to be ready...", slog.String("host", emulatorHost), slog.Any("error", err))
-		time.Sleep(2 * time.Second)
+	sink := logsink.New(writer, mode)
+	if err := sink.Run(ctx, client, topicIDs); err != nil {
+		logger.ErrorContext(ctx, "Log sink stopped", slog.Any("error", err))
 	}
 }